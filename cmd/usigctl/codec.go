@@ -0,0 +1,88 @@
+// Copyright (c) 2018 NEC Laboratories Europe GmbH.
+//
+// Authors: Wenting Li     <wenting.li@neclab.eu>
+//          Sergey Fedorov <sergey.fedorov@neclab.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+
+	"github.com/ynamiki/minbft/usig"
+)
+
+func runEncode(args []string) error {
+	fs := flag.NewFlagSet("encode", flag.ExitOnError)
+	epoch := fs.Uint64("epoch", 0, "UI epoch")
+	counter := fs.Uint64("counter", 0, "UI counter")
+	cert := fs.String("cert", "", "hex-encoded certificate bytes")
+	alg := fs.Uint("alg", 0, "certificate algorithm ID")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	certBytes, err := hex.DecodeString(*cert)
+	if err != nil {
+		return fmt.Errorf("encode: invalid -cert: %w", err)
+	}
+
+	ui := usig.UI{
+		Epoch:     *epoch,
+		Counter:   *counter,
+		Cert:      certBytes,
+		CertAlgID: usig.CertAlgID(*alg),
+	}
+
+	data, err := ui.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+
+	fmt.Println(hex.EncodeToString(data))
+	return nil
+}
+
+func runDecode(args []string) error {
+	fs := flag.NewFlagSet("decode", flag.ExitOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("decode: exactly one hex-encoded UI blob argument is required")
+	}
+
+	data, err := hex.DecodeString(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("decode: invalid hex: %w", err)
+	}
+
+	var ui usig.UI
+	if err := ui.UnmarshalBinary(data); err != nil {
+		return fmt.Errorf("decode: %w", err)
+	}
+
+	fmt.Printf("epoch:   %d\n", ui.Epoch)
+	fmt.Printf("counter: %d\n", ui.Counter)
+	fmt.Printf("alg:     %d\n", ui.CertAlgID)
+	fmt.Printf("cert:    %s\n", hex.EncodeToString(ui.Cert))
+	for _, ext := range ui.Extensions {
+		fmt.Printf("ext[%d]:  %s\n", ext.Tag, hex.EncodeToString(ext.Value))
+	}
+	fmt.Printf("string:  %s\n", ui.String())
+
+	return nil
+}
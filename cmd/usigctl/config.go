@@ -0,0 +1,51 @@
+// Copyright (c) 2018 NEC Laboratories Europe GmbH.
+//
+// Authors: Wenting Li     <wenting.li@neclab.eu>
+//          Sergey Fedorov <sergey.fedorov@neclab.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ynamiki/minbft/usig"
+)
+
+func runConfig(args []string) error {
+	fs := flag.NewFlagSet("config", flag.ExitOnError)
+	backend := fs.String("backend", "", "USIG backend name, as registered with usig.Register (required)")
+	backendConfig := fs.String("backend-config", "", "file containing the raw config to pass to that backend's Factory (optional)")
+	out := fs.String("out", "", "file to write the node config to (required)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *backend == "" || *out == "" {
+		return fmt.Errorf("config: -backend and -out are required")
+	}
+
+	var raw json.RawMessage
+	if *backendConfig != "" {
+		data, err := os.ReadFile(*backendConfig)
+		if err != nil {
+			return fmt.Errorf("config: %w", err)
+		}
+		raw = data
+	}
+
+	return writeJSONFile(*out, usig.NodeConfig{Backend: *backend, BackendConfig: raw})
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2018 NEC Laboratories Europe GmbH.
+//
+// Authors: Wenting Li     <wenting.li@neclab.eu>
+//          Sergey Fedorov <sergey.fedorov@neclab.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ynamiki/minbft/usig"
+)
+
+func runGenesis(args []string) error {
+	fs := flag.NewFlagSet("genesis", flag.ExitOnError)
+	out := fs.String("out", "", "file to write the genesis bundle to (required)")
+	keyPath := fs.String("key", "", "PEM-encoded ed25519 private key (PKCS8) to sign the bundle with; left unsigned if omitted")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *out == "" {
+		return fmt.Errorf("genesis: -out is required")
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("genesis: at least one identity file (as produced by \"usigctl genkey\") must be given")
+	}
+
+	var bundle usig.GenesisBundle
+	for _, path := range fs.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("genesis: %w", err)
+		}
+		var entry usig.GenesisEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return fmt.Errorf("genesis: invalid identity file %s: %w", path, err)
+		}
+		bundle.Entries = append(bundle.Entries, entry)
+	}
+
+	canonical, err := json.Marshal(bundle.Entries)
+	if err != nil {
+		return err
+	}
+
+	if *keyPath != "" {
+		priv, err := readEd25519Key(*keyPath)
+		if err != nil {
+			return fmt.Errorf("genesis: %w", err)
+		}
+		bundle.Signature = ed25519.Sign(priv, canonical)
+	}
+
+	return writeJSONFile(*out, bundle)
+}
+
+func readEd25519Key(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found in %s", path)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s does not contain an ed25519 private key", path)
+	}
+
+	return priv, nil
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2018 NEC Laboratories Europe GmbH.
+//
+// Authors: Wenting Li     <wenting.li@neclab.eu>
+//          Sergey Fedorov <sergey.fedorov@neclab.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ynamiki/minbft/usig"
+)
+
+func runGenkey(args []string) error {
+	fs := flag.NewFlagSet("genkey", flag.ExitOnError)
+	backend := fs.String("backend", "sw", "USIG backend to generate identity material for (sw, tpm, sgx)")
+	replicaID := fs.String("replica-id", "", "replica identifier to embed in the identity file (required)")
+	keyOut := fs.String("key-out", "", "file to write private key material to (required)")
+	identityOut := fs.String("identity-out", "", "file to write the public genesis entry to (required)")
+	allowInsecureSW := fs.Bool("allow-insecure-sw", false, "acknowledge that the sw backend's genesis entry carries its shared signing key in the clear, and proceed anyway (development and testing only; never a real deployment)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *replicaID == "" || *keyOut == "" || *identityOut == "" {
+		return fmt.Errorf("genkey: -replica-id, -key-out and -identity-out are required")
+	}
+
+	switch *backend {
+	case "sw":
+		if !*allowInsecureSW {
+			return fmt.Errorf("genkey: the sw backend's genesis entry carries its shared HMAC key in the clear, handing every replica that reads the bundle the power to forge this replica's UIs; it is not a real USIG and must never be used for a production deployment. Pass -allow-insecure-sw to generate one anyway for development or testing")
+		}
+		return genkeySW(*replicaID, *keyOut, *identityOut)
+	case "tpm", "sgx":
+		return fmt.Errorf("genkey: backend %q requires a live device; enroll it out-of-band (EK/AK enrollment and NV counter provisioning for tpm, enclave measurement and key sealing for sgx) and assemble its genesis entry by hand", *backend)
+	default:
+		return fmt.Errorf("genkey: unknown backend %q", *backend)
+	}
+}
+
+// swKeyFile is the on-disk representation of private sw key material
+type swKeyFile struct {
+	Backend string `json:"backend"`
+	Key     []byte `json:"key"`
+}
+
+func genkeySW(replicaID, keyOut, identityOut string) error {
+	key, err := usig.GenerateSWKey()
+	if err != nil {
+		return err
+	}
+
+	u, err := usig.NewSW(usig.SWConfig{Key: key})
+	if err != nil {
+		return err
+	}
+
+	material, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+
+	if err := writeJSONFile(keyOut, swKeyFile{Backend: "sw", Key: key}); err != nil {
+		return fmt.Errorf("genkey: failed to write key file: %w", err)
+	}
+
+	entry := usig.GenesisEntry{
+		ReplicaID:      replicaID,
+		USIGID:         u.ID(),
+		CertAlgID:      usig.CertAlgHMAC,
+		PublicMaterial: material,
+	}
+	if err := writeJSONFile(identityOut, entry); err != nil {
+		return fmt.Errorf("genkey: failed to write identity file: %w", err)
+	}
+
+	return nil
+}
+
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	return os.WriteFile(path, data, 0600)
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2018 NEC Laboratories Europe GmbH.
+//
+// Authors: Wenting Li     <wenting.li@neclab.eu>
+//          Sergey Fedorov <sergey.fedorov@neclab.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command usigctl generates and distributes USIG identity material,
+// assembles it into a genesis bundle and node config, and offers
+// offline encode/decode/verify helpers for UI blobs.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+var commands = map[string]func(args []string) error{
+	"genkey":  runGenkey,
+	"genesis": runGenesis,
+	"config":  runConfig,
+	"encode":  runEncode,
+	"decode":  runDecode,
+	"verify":  runVerify,
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+
+	if err := cmd(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "usigctl: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `usage: usigctl <command> [arguments]
+
+Commands:
+  genkey    generate per-replica USIG identity material
+  genesis   assemble identity files into a genesis bundle, signed if -key is given
+  config    emit a node config selecting and configuring a USIG backend
+  encode    encode UI fields to a hex blob
+  decode    decode a hex UI blob to its fields
+  verify    verify a UI blob against a message and a genesis bundle, offline
+
+Run "usigctl <command> -h" for the flags a command accepts.`)
+}
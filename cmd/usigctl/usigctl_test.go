@@ -0,0 +1,201 @@
+// Copyright (c) 2018 NEC Laboratories Europe GmbH.
+//
+// Authors: Wenting Li     <wenting.li@neclab.eu>
+//          Sergey Fedorov <sergey.fedorov@neclab.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ynamiki/minbft/usig"
+)
+
+func TestGenkeyGenesisVerifyRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	keyPath := filepath.Join(dir, "replica0.key")
+	identityPath := filepath.Join(dir, "replica0.identity.json")
+	if err := runGenkey([]string{
+		"-backend", "sw",
+		"-replica-id", "replica0",
+		"-key-out", keyPath,
+		"-identity-out", identityPath,
+		"-allow-insecure-sw",
+	}); err != nil {
+		t.Fatalf("runGenkey() failed: %s", err)
+	}
+
+	genesisPath := filepath.Join(dir, "genesis.json")
+	if err := runGenesis([]string{"-out", genesisPath, identityPath}); err != nil {
+		t.Fatalf("runGenesis() failed: %s", err)
+	}
+
+	u, err := loadSWFromKeyFile(keyPath)
+	if err != nil {
+		t.Fatalf("loadSWFromKeyFile() failed: %s", err)
+	}
+
+	message := []byte("request-payload")
+	ui, err := u.CreateUI(message)
+	if err != nil {
+		t.Fatalf("CreateUI() failed: %s", err)
+	}
+	uiData, err := ui.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() failed: %s", err)
+	}
+
+	messagePath := filepath.Join(dir, "message")
+	if err := os.WriteFile(messagePath, message, 0600); err != nil {
+		t.Fatalf("failed to write message file: %s", err)
+	}
+
+	if err := runVerify([]string{
+		"-genesis", genesisPath,
+		"-replica-id", "replica0",
+		"-message", messagePath,
+		"-ui", hex.EncodeToString(uiData),
+	}); err != nil {
+		t.Errorf("runVerify() failed for a genuine UI: %s", err)
+	}
+
+	tamperedPath := filepath.Join(dir, "tampered-message")
+	if err := os.WriteFile(tamperedPath, []byte("not the signed message"), 0600); err != nil {
+		t.Fatalf("failed to write tampered message file: %s", err)
+	}
+	if err := runVerify([]string{
+		"-genesis", genesisPath,
+		"-replica-id", "replica0",
+		"-message", tamperedPath,
+		"-ui", hex.EncodeToString(uiData),
+	}); err == nil {
+		t.Error("runVerify() succeeded against a tampered message, want error")
+	}
+}
+
+func loadSWFromKeyFile(path string) (usig.USIG, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var keyFile swKeyFile
+	if err := json.Unmarshal(data, &keyFile); err != nil {
+		return nil, err
+	}
+
+	return usig.NewSW(usig.SWConfig{Key: keyFile.Key})
+}
+
+func TestGenesisVerifySignature(t *testing.T) {
+	dir := t.TempDir()
+
+	identityPath := filepath.Join(dir, "replica0.identity.json")
+	if err := runGenkey([]string{
+		"-backend", "sw",
+		"-replica-id", "replica0",
+		"-key-out", filepath.Join(dir, "replica0.key"),
+		"-identity-out", identityPath,
+		"-allow-insecure-sw",
+	}); err != nil {
+		t.Fatalf("runGenkey() failed: %s", err)
+	}
+
+	signingKeyPath, pubKeyPath := writeEd25519KeyPair(t)
+
+	genesisPath := filepath.Join(dir, "genesis.json")
+	if err := runGenesis([]string{"-out", genesisPath, "-key", signingKeyPath, identityPath}); err != nil {
+		t.Fatalf("runGenesis() failed: %s", err)
+	}
+
+	bundleData, err := os.ReadFile(genesisPath)
+	if err != nil {
+		t.Fatalf("failed to read genesis bundle: %s", err)
+	}
+	var bundle usig.GenesisBundle
+	if err := json.Unmarshal(bundleData, &bundle); err != nil {
+		t.Fatalf("failed to parse genesis bundle: %s", err)
+	}
+
+	if err := verifyGenesisSignature(bundle, pubKeyPath); err != nil {
+		t.Errorf("verifyGenesisSignature() failed for a genuinely signed bundle: %s", err)
+	}
+
+	_, otherPubKeyPath := writeEd25519KeyPair(t)
+	if err := verifyGenesisSignature(bundle, otherPubKeyPath); err == nil {
+		t.Error("verifyGenesisSignature() succeeded against the wrong public key, want error")
+	}
+
+	unsigned := bundle
+	unsigned.Signature = nil
+	if err := verifyGenesisSignature(unsigned, pubKeyPath); err == nil {
+		t.Error("verifyGenesisSignature() succeeded for an unsigned bundle, want error")
+	}
+}
+
+func writeEd25519KeyPair(t *testing.T) (privPath, pubPath string) {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ed25519.GenerateKey() failed: %s", err)
+	}
+
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey() failed: %s", err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey() failed: %s", err)
+	}
+
+	privPath = filepath.Join(dir, "genesis.key.pem")
+	pubPath = filepath.Join(dir, "genesis.pub.pem")
+
+	if err := os.WriteFile(privPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}), 0600); err != nil {
+		t.Fatalf("failed to write private key: %s", err)
+	}
+	if err := os.WriteFile(pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0644); err != nil {
+		t.Fatalf("failed to write public key: %s", err)
+	}
+
+	return privPath, pubPath
+}
+
+func TestGenkeySWRefusesWithoutAcknowledgement(t *testing.T) {
+	dir := t.TempDir()
+
+	err := runGenkey([]string{
+		"-backend", "sw",
+		"-replica-id", "replica0",
+		"-key-out", filepath.Join(dir, "replica0.key"),
+		"-identity-out", filepath.Join(dir, "replica0.identity.json"),
+	})
+	if err == nil {
+		t.Error("runGenkey() for sw without -allow-insecure-sw succeeded, want error")
+	}
+}
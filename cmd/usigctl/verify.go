@@ -0,0 +1,156 @@
+// Copyright (c) 2018 NEC Laboratories Europe GmbH.
+//
+// Authors: Wenting Li     <wenting.li@neclab.eu>
+//          Sergey Fedorov <sergey.fedorov@neclab.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ynamiki/minbft/usig"
+)
+
+// backendVerifier checks a single UI offline against the public
+// material enrolled for its issuing replica
+type backendVerifier func(entry usig.GenesisEntry, message []byte, ui *usig.UI) error
+
+// verifiers is populated by each backend's init function, mirroring
+// usig.Register/usig.Open: a backend built without its corresponding
+// build tag (tpm, sgx) simply has no entry here
+var verifiers = map[usig.CertAlgID]backendVerifier{}
+
+func registerVerifier(alg usig.CertAlgID, fn backendVerifier) {
+	verifiers[alg] = fn
+}
+
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	genesisPath := fs.String("genesis", "", "genesis bundle file (required)")
+	genesisPubKeyPath := fs.String("genesis-pubkey", "", "PEM-encoded ed25519 public key (PKIX) the genesis bundle must be signed with; if omitted, the bundle's signature (if any) is not checked")
+	replicaID := fs.String("replica-id", "", "replica ID in the genesis bundle whose UI is being verified (required)")
+	messagePath := fs.String("message", "", "file containing the exact message bytes the UI was created over (required)")
+	uiHex := fs.String("ui", "", "hex-encoded UI blob, as produced by \"usigctl encode\" (required)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *genesisPath == "" || *replicaID == "" || *messagePath == "" || *uiHex == "" {
+		return fmt.Errorf("verify: -genesis, -replica-id, -message and -ui are all required")
+	}
+
+	bundleData, err := os.ReadFile(*genesisPath)
+	if err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+	var bundle usig.GenesisBundle
+	if err := json.Unmarshal(bundleData, &bundle); err != nil {
+		return fmt.Errorf("verify: invalid genesis bundle: %w", err)
+	}
+
+	if *genesisPubKeyPath != "" {
+		if err := verifyGenesisSignature(bundle, *genesisPubKeyPath); err != nil {
+			return fmt.Errorf("verify: %w", err)
+		}
+	}
+
+	var entry *usig.GenesisEntry
+	for i := range bundle.Entries {
+		if bundle.Entries[i].ReplicaID == *replicaID {
+			entry = &bundle.Entries[i]
+			break
+		}
+	}
+	if entry == nil {
+		return fmt.Errorf("verify: no entry for replica %q in genesis bundle", *replicaID)
+	}
+
+	message, err := os.ReadFile(*messagePath)
+	if err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+
+	uiData, err := hex.DecodeString(*uiHex)
+	if err != nil {
+		return fmt.Errorf("verify: invalid -ui: %w", err)
+	}
+	var ui usig.UI
+	if err := ui.UnmarshalBinary(uiData); err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+
+	verify, ok := verifiers[entry.CertAlgID]
+	if !ok {
+		return fmt.Errorf("verify: no verifier registered for certificate algorithm %d (usigctl built without support for it?)", entry.CertAlgID)
+	}
+
+	if err := verify(*entry, message, &ui); err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+
+	fmt.Printf("OK: %s\n", ui.String())
+	return nil
+}
+
+// verifyGenesisSignature checks bundle.Signature against the canonical
+// encoding of bundle.Entries (the same bytes runGenesis signs), using
+// the ed25519 public key at pubKeyPath. It fails closed: a missing or
+// malformed signature is rejected exactly like a bad one
+func verifyGenesisSignature(bundle usig.GenesisBundle, pubKeyPath string) error {
+	pub, err := readEd25519PublicKey(pubKeyPath)
+	if err != nil {
+		return err
+	}
+
+	canonical, err := json.Marshal(bundle.Entries)
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(pub, canonical, bundle.Signature) {
+		return fmt.Errorf("genesis bundle signature verification failed")
+	}
+	return nil
+}
+
+func readEd25519PublicKey(path string) (ed25519.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found in %s", path)
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s does not contain an ed25519 public key", path)
+	}
+
+	return pub, nil
+}
@@ -0,0 +1,48 @@
+// Copyright (c) 2018 NEC Laboratories Europe GmbH.
+//
+// Authors: Wenting Li     <wenting.li@neclab.eu>
+//          Sergey Fedorov <sergey.fedorov@neclab.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build sgx
+
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ynamiki/minbft/usig"
+)
+
+func init() {
+	registerVerifier(usig.CertAlgSGX, verifySGX)
+}
+
+// verifySGX checks the UI's report against the peer's enrolled
+// MRENCLAVE measurement, without needing a live SGXDevice
+func verifySGX(entry usig.GenesisEntry, message []byte, ui *usig.UI) error {
+	var measurementHex string
+	if err := json.Unmarshal(entry.PublicMaterial, &measurementHex); err != nil {
+		return fmt.Errorf("sgx: invalid public material: %w", err)
+	}
+
+	measurement, err := hex.DecodeString(measurementHex)
+	if err != nil {
+		return fmt.Errorf("sgx: invalid measurement hex: %w", err)
+	}
+
+	return usig.VerifySGXReport(measurement, ui, message)
+}
@@ -0,0 +1,41 @@
+// Copyright (c) 2018 NEC Laboratories Europe GmbH.
+//
+// Authors: Wenting Li     <wenting.li@neclab.eu>
+//          Sergey Fedorov <sergey.fedorov@neclab.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ynamiki/minbft/usig"
+)
+
+func init() {
+	registerVerifier(usig.CertAlgHMAC, verifySW)
+}
+
+// verifySW checks the UI against the shared HMAC key enrolled as the
+// sw backend's PublicMaterial despite the name (see usig.GenesisEntry),
+// without needing a full swUSIG instance
+func verifySW(entry usig.GenesisEntry, message []byte, ui *usig.UI) error {
+	var key []byte
+	if err := json.Unmarshal(entry.PublicMaterial, &key); err != nil {
+		return fmt.Errorf("sw: invalid public material: %w", err)
+	}
+
+	return usig.VerifySWMAC(key, ui, message)
+}
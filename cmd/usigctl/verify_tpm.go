@@ -0,0 +1,47 @@
+// Copyright (c) 2018 NEC Laboratories Europe GmbH.
+//
+// Authors: Wenting Li     <wenting.li@neclab.eu>
+//          Sergey Fedorov <sergey.fedorov@neclab.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build tpm
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ynamiki/minbft/usig"
+)
+
+func init() {
+	registerVerifier(usig.CertAlgTPM, verifyTPM)
+}
+
+// verifyTPM checks the UI's quote against the peer's enrolled AK
+// certificate, without needing a live TPMDevice
+func verifyTPM(entry usig.GenesisEntry, message []byte, ui *usig.UI) error {
+	var pemCert string
+	if err := json.Unmarshal(entry.PublicMaterial, &pemCert); err != nil {
+		return fmt.Errorf("tpm: invalid public material: %w", err)
+	}
+
+	certs, err := usig.DecodePeerAKCerts(map[string]string{entry.ReplicaID: pemCert})
+	if err != nil {
+		return fmt.Errorf("tpm: %w", err)
+	}
+
+	return usig.VerifyTPMQuote(certs[entry.ReplicaID], ui, message)
+}
@@ -0,0 +1,38 @@
+// Copyright (c) 2018 NEC Laboratories Europe GmbH.
+//
+// Authors: Wenting Li     <wenting.li@neclab.eu>
+//          Sergey Fedorov <sergey.fedorov@neclab.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package usig
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// attestationData builds the byte string that hardware-rooted backends
+// (TPM, SGX) commit to when certifying a UI: the instance epoch, the
+// counter value assigned to message, and a digest of message itself.
+// Shared so a TPM quote and an SGX report bind to the same layout
+func attestationData(epoch, counter uint64, message []byte) []byte {
+	digest := sha256.Sum256(message)
+
+	data := make([]byte, 16+len(digest))
+	binary.LittleEndian.PutUint64(data[0:8], epoch)
+	binary.LittleEndian.PutUint64(data[8:16], counter)
+	copy(data[16:], digest[:])
+
+	return data
+}
@@ -0,0 +1,160 @@
+package usig
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// slowUSIG mocks a backend whose signing operation is expensive, the
+// way a TPM- or SGX-backed one would be, but does not implement
+// BatchUSIG: every CreateUI call pays signLatency on its own
+type slowUSIG struct {
+	signLatency time.Duration
+	counter     uint64
+}
+
+func (s *slowUSIG) CreateUI(message []byte) (*UI, error) {
+	time.Sleep(s.signLatency)
+	s.counter++
+	return &UI{Epoch: 1, Counter: s.counter, Cert: message}, nil
+}
+
+func (s *slowUSIG) VerifyUI(message []byte, ui *UI, usigID []byte) error {
+	return nil
+}
+
+func (s *slowUSIG) ID() []byte {
+	return []byte("slow")
+}
+
+// slowBatchUSIG is the same mocked slow signer, except it implements
+// BatchUSIG and pays signLatency once per batch instead of once per
+// message, the shape a real TPM/SGX backend's Merkle-root batching
+// takes
+type slowBatchUSIG struct {
+	slowUSIG
+}
+
+func (s *slowBatchUSIG) CreateUIBatch(messages [][]byte) ([]*UI, error) {
+	time.Sleep(s.signLatency)
+
+	uis := make([]*UI, len(messages))
+	for i, message := range messages {
+		s.counter++
+		uis[i] = &UI{Epoch: 1, Counter: s.counter, Cert: message}
+	}
+	return uis, nil
+}
+
+func (s *slowBatchUSIG) VerifyUIBatch(messages [][]byte, uis []*UI, usigID []byte) error {
+	return nil
+}
+
+func TestCreateUIBatchFallsBackToLooping(t *testing.T) {
+	u := &slowUSIG{}
+	messages := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+
+	uis, err := CreateUIBatch(u, messages)
+	if err != nil {
+		t.Fatalf("CreateUIBatch() failed: %s", err)
+	}
+	if len(uis) != len(messages) {
+		t.Fatalf("CreateUIBatch() returned %d UIs, want %d", len(uis), len(messages))
+	}
+	for i, ui := range uis {
+		if ui.Counter != uint64(i+1) {
+			t.Errorf("uis[%d].Counter = %d, want %d", i, ui.Counter, i+1)
+		}
+	}
+}
+
+func TestCreateUIBatchUsesBatchUSIG(t *testing.T) {
+	u := &slowBatchUSIG{}
+	messages := [][]byte{[]byte("a"), []byte("b")}
+
+	uis, err := CreateUIBatch(u, messages)
+	if err != nil {
+		t.Fatalf("CreateUIBatch() failed: %s", err)
+	}
+	if len(uis) != len(messages) {
+		t.Fatalf("CreateUIBatch() returned %d UIs, want %d", len(uis), len(messages))
+	}
+}
+
+func TestVerifyUIBatchFallsBackToLooping(t *testing.T) {
+	keyA, err := GenerateSWKey()
+	if err != nil {
+		t.Fatalf("GenerateSWKey() failed: %s", err)
+	}
+	a, err := NewSW(SWConfig{Key: keyA})
+	if err != nil {
+		t.Fatalf("NewSW() failed: %s", err)
+	}
+
+	// b enrolls a's key so it can verify a's UIs, the same way a peer
+	// verifier is enrolled for the tpm and sgx backends
+	b, err := NewSW(SWConfig{PeerKeys: map[string][]byte{
+		fmt.Sprintf("%x", a.ID()): keyA,
+	}})
+	if err != nil {
+		t.Fatalf("NewSW() failed: %s", err)
+	}
+
+	messages := [][]byte{[]byte("a"), []byte("b")}
+	uis, err := CreateUIBatch(a, messages)
+	if err != nil {
+		t.Fatalf("CreateUIBatch() failed: %s", err)
+	}
+
+	if err := VerifyUIBatch(b, messages, uis, a.ID()); err != nil {
+		t.Errorf("VerifyUIBatch() failed for a genuine batch: %s", err)
+	}
+
+	tampered := *uis[0]
+	tampered.Counter++
+	if err := VerifyUIBatch(b, messages, []*UI{&tampered, uis[1]}, a.ID()); err == nil {
+		t.Error("VerifyUIBatch() succeeded for a tampered UI, want error")
+	}
+}
+
+func TestVerifyUIBatchMismatchedLengths(t *testing.T) {
+	u := &slowUSIG{}
+	if err := VerifyUIBatch(u, [][]byte{[]byte("a")}, nil, nil); err == nil {
+		t.Error("VerifyUIBatch() with mismatched lengths succeeded, want error")
+	}
+}
+
+// BenchmarkCreateUIBatchLoop and BenchmarkCreateUIBatchBatched model the
+// throughput difference CreateUIBatch is meant to unlock for a
+// TPM/SGX-like backend whose signing operation costs milliseconds: one
+// pays that cost once per message, the other once per batch
+func BenchmarkCreateUIBatchLoop(b *testing.B) {
+	u := &slowUSIG{signLatency: time.Millisecond}
+	messages := make([][]byte, 32)
+	for i := range messages {
+		messages[i] = []byte(fmt.Sprintf("msg-%d", i))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := CreateUIBatch(u, messages); err != nil {
+			b.Fatalf("CreateUIBatch() failed: %s", err)
+		}
+	}
+}
+
+func BenchmarkCreateUIBatchBatched(b *testing.B) {
+	u := &slowBatchUSIG{slowUSIG{signLatency: time.Millisecond}}
+	messages := make([][]byte, 32)
+	for i := range messages {
+		messages[i] = []byte(fmt.Sprintf("msg-%d", i))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := CreateUIBatch(u, messages); err != nil {
+			b.Fatalf("CreateUIBatch() failed: %s", err)
+		}
+	}
+}
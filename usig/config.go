@@ -0,0 +1,70 @@
+// Copyright (c) 2018 NEC Laboratories Europe GmbH.
+//
+// Authors: Wenting Li     <wenting.li@neclab.eu>
+//          Sergey Fedorov <sergey.fedorov@neclab.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package usig
+
+import "encoding/json"
+
+// GenesisEntry enrolls one replica's USIG identity for peer
+// verification. It is produced by cmd/usigctl at cluster bootstrap and
+// consumed by every other replica to populate its USIG verifier table
+type GenesisEntry struct {
+	// ReplicaID identifies the replica this entry belongs to
+	ReplicaID string `json:"replica_id"`
+
+	// USIGID is the value the replica's USIG.ID returns; it is the
+	// usigID a peer passes to VerifyUI when checking this replica's UIs
+	USIGID []byte `json:"usig_id"`
+
+	// CertAlgID is the certificate algorithm the replica's USIG
+	// backend produces, letting peers reject a UI created under a
+	// different algorithm before attempting verification
+	CertAlgID CertAlgID `json:"cert_alg_id"`
+
+	// PublicMaterial is backend-specific verification material: an AK
+	// certificate (PEM) for tpm, an enclave measurement for sgx.
+	// For the sw backend, this actually carries the shared HMAC key
+	// and must be handled as sensitive despite the field's name: HMAC
+	// verification is symmetric, so there is no public/private split
+	PublicMaterial json.RawMessage `json:"public_material"`
+}
+
+// GenesisBundle is the collection of GenesisEntry distributed to every
+// replica at cluster bootstrap, optionally signed so replicas can
+// authenticate it before trusting its contents
+type GenesisBundle struct {
+	Entries []GenesisEntry `json:"entries"`
+
+	// Signature, if present, is a detached signature over the
+	// canonical JSON encoding of Entries
+	Signature []byte `json:"signature,omitempty"`
+}
+
+// NodeConfig is the subset of a replica's configuration that selects
+// and configures its local USIG backend, as loaded by Load
+type NodeConfig struct {
+	// Backend is the name a USIG backend was Register-ed under
+	Backend string `json:"backend"`
+
+	// BackendConfig is passed unmodified to that backend's Factory
+	BackendConfig json.RawMessage `json:"backend_config"`
+}
+
+// Load opens the USIG backend selected by cfg
+func Load(cfg NodeConfig) (USIG, error) {
+	return Open(cfg.Backend, cfg.BackendConfig)
+}
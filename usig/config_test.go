@@ -0,0 +1,20 @@
+package usig
+
+import "testing"
+
+func TestLoadSWBackend(t *testing.T) {
+	u, err := Load(NodeConfig{Backend: "sw"})
+	if err != nil {
+		t.Fatalf("Load() failed: %s", err)
+	}
+
+	if _, err := u.CreateUI([]byte("msg")); err != nil {
+		t.Errorf("CreateUI() failed: %s", err)
+	}
+}
+
+func TestLoadUnknownBackend(t *testing.T) {
+	if _, err := Load(NodeConfig{Backend: "does-not-exist"}); err == nil {
+		t.Error("Load() with unknown backend succeeded, want error")
+	}
+}
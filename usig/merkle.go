@@ -0,0 +1,235 @@
+// Copyright (c) 2018 NEC Laboratories Europe GmbH.
+//
+// Authors: Wenting Li     <wenting.li@neclab.eu>
+//          Sergey Fedorov <sergey.fedorov@neclab.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package usig
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+)
+
+// extTagMerkleIndex and extTagMerkleSibling tag the UI.Extensions
+// entries a batch-producing backend (tpm, sgx) attaches to every UI it
+// hands out from CreateUIBatch: the UI's position among the batch's
+// leaves, and its sibling path up to the root the batch's single
+// attestation actually commits to. Their presence on a UI is what tells
+// quotedData it was produced by a batch rather than a single CreateUI
+// call
+const (
+	extTagMerkleIndex   byte = 2
+	extTagMerkleSibling byte = 3
+)
+
+// merkleLeaf hashes the attestation data for one message of a batch
+// into this message's leaf in the batch's Merkle tree
+func merkleLeaf(epoch, counter uint64, message []byte) [sha256.Size]byte {
+	return sha256.Sum256(attestationData(epoch, counter, message))
+}
+
+// hashPair hashes two Merkle tree nodes into their parent
+func hashPair(left, right [sha256.Size]byte) [sha256.Size]byte {
+	h := sha256.New()
+	h.Write(left[:])
+	h.Write(right[:])
+	var out [sha256.Size]byte
+	copy(out[:], h.Sum(nil))
+	return out
+}
+
+// merkleRoot builds a binary Merkle tree over leaves, duplicating the
+// last node of any odd-sized level so every level pairs off evenly. It
+// returns the root together with each leaf's sibling path, in leaf
+// order, so that a single attestation over the root can later certify
+// any one leaf in isolation
+func merkleRoot(leaves [][sha256.Size]byte) (root [sha256.Size]byte, paths [][][sha256.Size]byte) {
+	paths = make([][][sha256.Size]byte, len(leaves))
+
+	level := append([][sha256.Size]byte(nil), leaves...)
+	index := make([]int, len(leaves))
+	for i := range index {
+		index[i] = i
+	}
+
+	for len(level) > 1 {
+		next := make([][sha256.Size]byte, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			left := level[i]
+			right := level[i]
+			if i+1 < len(level) {
+				right = level[i+1]
+			}
+			next[i/2] = hashPair(left, right)
+		}
+
+		for leaf, i := range index {
+			if i%2 == 0 {
+				sib := level[i]
+				if i+1 < len(level) {
+					sib = level[i+1]
+				}
+				paths[leaf] = append(paths[leaf], sib)
+			} else {
+				paths[leaf] = append(paths[leaf], level[i-1])
+			}
+			index[leaf] = i / 2
+		}
+
+		level = next
+	}
+
+	return level[0], paths
+}
+
+// merkleVerify recomputes the root reachable from leaf by walking path,
+// using index to pick the hashing order at each level the same way
+// merkleRoot assigned it
+func merkleVerify(leaf [sha256.Size]byte, index int, path [][sha256.Size]byte) [sha256.Size]byte {
+	current := leaf
+	for _, sib := range path {
+		if index%2 == 0 {
+			current = hashPair(current, sib)
+		} else {
+			current = hashPair(sib, current)
+		}
+		index /= 2
+	}
+	return current
+}
+
+// merklePathExtensions encodes index and path as the UIExtension pair a
+// batch-producing backend attaches to a UI, so a later, isolated
+// VerifyUI call can recompute the batch root this UI's attestation
+// actually commits to
+func merklePathExtensions(index int, path [][sha256.Size]byte) []UIExtension {
+	idxBuf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(idxBuf, uint64(index))
+
+	sib := make([]byte, 0, len(path)*sha256.Size)
+	for _, s := range path {
+		sib = append(sib, s[:]...)
+	}
+
+	return []UIExtension{
+		{Tag: extTagMerkleIndex, Value: idxBuf[:n]},
+		{Tag: extTagMerkleSibling, Value: sib},
+	}
+}
+
+// tryDecodeMerklePath extracts the Merkle index and sibling path from
+// ui's extensions. ok is false when neither extension is present,
+// meaning ui was produced by a single-message CreateUI rather than a
+// batch
+func tryDecodeMerklePath(extensions []UIExtension) (index int, path [][sha256.Size]byte, ok bool, err error) {
+	var haveIndex, haveSibling bool
+	var sib []byte
+
+	for _, ext := range extensions {
+		switch ext.Tag {
+		case extTagMerkleIndex:
+			v, n := binary.Uvarint(ext.Value)
+			if n <= 0 {
+				return 0, nil, false, fmt.Errorf("usig: malformed merkle index extension")
+			}
+			index, haveIndex = int(v), true
+		case extTagMerkleSibling:
+			sib, haveSibling = ext.Value, true
+		}
+	}
+
+	if !haveIndex && !haveSibling {
+		return 0, nil, false, nil
+	}
+	if !haveIndex || !haveSibling {
+		return 0, nil, false, fmt.Errorf("usig: incomplete merkle path extensions")
+	}
+	if len(sib)%sha256.Size != 0 {
+		return 0, nil, false, fmt.Errorf("usig: malformed merkle sibling path length %d", len(sib))
+	}
+
+	path = make([][sha256.Size]byte, len(sib)/sha256.Size)
+	for i := range path {
+		copy(path[i][:], sib[i*sha256.Size:(i+1)*sha256.Size])
+	}
+
+	return index, path, true, nil
+}
+
+// quotedData returns the bytes a hardware-rooted backend's attestation
+// actually commits to for ui: attestationData itself for a UI produced
+// by a single CreateUI call, or the batch root recomputed from the
+// embedded Merkle sibling path for a UI produced by CreateUIBatch. This
+// lets VerifyUI check a batch-produced UI on its own, without the rest
+// of the batch it was created with
+func quotedData(ui *UI, message []byte) ([]byte, error) {
+	index, path, ok, err := tryDecodeMerklePath(ui.Extensions)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return attestationData(ui.Epoch, ui.Counter, message), nil
+	}
+
+	leaf := merkleLeaf(ui.Epoch, ui.Counter, message)
+	root := merkleVerify(leaf, index, path)
+	return root[:], nil
+}
+
+// verifyMerkleBatch checks that every uis[i] is consistent with
+// messages[i]: it recomputes each UI's leaf, walks its embedded sibling
+// path to a root, requires every UI in the batch to agree on that root
+// and to carry the same attestation, and then hands the root to
+// verifyRoot to check the attestation itself. It is the shared core of
+// VerifyUIBatch for both the tpm and sgx backends
+func verifyMerkleBatch(messages [][]byte, uis []*UI, wantAlg CertAlgID, verifyRoot func(root []byte, cert []byte) error) error {
+	if len(messages) != len(uis) {
+		return fmt.Errorf("usig: batch: %d messages but %d UIs", len(messages), len(uis))
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+
+	cert := uis[0].Cert
+	var root [sha256.Size]byte
+
+	for i, ui := range uis {
+		if ui.CertAlgID != wantAlg {
+			return fmt.Errorf("usig: batch: message %d: UI certificate algorithm %d does not match backend", i, ui.CertAlgID)
+		}
+		if string(ui.Cert) != string(cert) {
+			return fmt.Errorf("usig: batch: message %d: does not share the batch's attestation", i)
+		}
+
+		index, path, ok, err := tryDecodeMerklePath(ui.Extensions)
+		if err != nil {
+			return fmt.Errorf("usig: batch: message %d: %w", i, err)
+		}
+		if !ok {
+			return fmt.Errorf("usig: batch: message %d: missing merkle path extensions", i)
+		}
+
+		leaf := merkleLeaf(ui.Epoch, ui.Counter, messages[i])
+		got := merkleVerify(leaf, index, path)
+		if i == 0 {
+			root = got
+		} else if got != root {
+			return fmt.Errorf("usig: batch: message %d: sibling path does not lead to the batch root", i)
+		}
+	}
+
+	return verifyRoot(root[:], cert)
+}
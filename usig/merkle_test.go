@@ -0,0 +1,98 @@
+package usig
+
+import (
+	"crypto/sha256"
+	"testing"
+)
+
+func TestMerkleRootVerifyRoundTrip(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 4, 5, 7, 8} {
+		leaves := make([][sha256.Size]byte, n)
+		for i := range leaves {
+			leaves[i] = merkleLeaf(1, uint64(i), []byte{byte(i)})
+		}
+
+		root, paths := merkleRoot(leaves)
+		for i, leaf := range leaves {
+			if got := merkleVerify(leaf, i, paths[i]); got != root {
+				t.Errorf("n=%d: merkleVerify(leaf %d) = %x, want root %x", n, i, got, root)
+			}
+		}
+	}
+}
+
+func TestMerklePathExtensionsRoundTrip(t *testing.T) {
+	leaves := make([][sha256.Size]byte, 4)
+	for i := range leaves {
+		leaves[i] = merkleLeaf(1, uint64(i), []byte{byte(i)})
+	}
+	_, paths := merkleRoot(leaves)
+
+	exts := merklePathExtensions(2, paths[2])
+	index, path, ok, err := tryDecodeMerklePath(exts)
+	if err != nil {
+		t.Fatalf("tryDecodeMerklePath() failed: %s", err)
+	}
+	if !ok {
+		t.Fatal("tryDecodeMerklePath() ok = false, want true")
+	}
+	if index != 2 {
+		t.Errorf("index = %d, want 2", index)
+	}
+	if len(path) != len(paths[2]) {
+		t.Fatalf("path length = %d, want %d", len(path), len(paths[2]))
+	}
+	for i := range path {
+		if path[i] != paths[2][i] {
+			t.Errorf("path[%d] = %x, want %x", i, path[i], paths[2][i])
+		}
+	}
+}
+
+func TestTryDecodeMerklePathAbsent(t *testing.T) {
+	_, _, ok, err := tryDecodeMerklePath(nil)
+	if err != nil {
+		t.Fatalf("tryDecodeMerklePath() failed: %s", err)
+	}
+	if ok {
+		t.Error("tryDecodeMerklePath() ok = true for a UI with no merkle extensions, want false")
+	}
+}
+
+func TestQuotedDataNonBatch(t *testing.T) {
+	ui := &UI{Epoch: 1, Counter: 2, CertAlgID: CertAlgHMAC}
+	message := []byte("msg")
+
+	got, err := quotedData(ui, message)
+	if err != nil {
+		t.Fatalf("quotedData() failed: %s", err)
+	}
+	want := attestationData(ui.Epoch, ui.Counter, message)
+	if string(got) != string(want) {
+		t.Errorf("quotedData() = %x, want %x", got, want)
+	}
+}
+
+func TestQuotedDataBatch(t *testing.T) {
+	messages := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+	leaves := make([][sha256.Size]byte, len(messages))
+	for i := range messages {
+		leaves[i] = merkleLeaf(1, uint64(i), messages[i])
+	}
+	root, paths := merkleRoot(leaves)
+
+	ui := &UI{
+		Epoch:      1,
+		Counter:    1,
+		CertAlgID:  CertAlgTPM,
+		Extensions: merklePathExtensions(1, paths[1]),
+	}
+
+	got, err := quotedData(ui, messages[1])
+	if err != nil {
+		t.Fatalf("quotedData() failed: %s", err)
+	}
+	if string(got) != string(root[:]) {
+		t.Errorf("quotedData() = %x, want batch root %x", got, root)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2018 NEC Laboratories Europe GmbH.
+//
+// Authors: Wenting Li     <wenting.li@neclab.eu>
+//          Sergey Fedorov <sergey.fedorov@neclab.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package usig
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Factory constructs a USIG backend from its raw, backend-specific
+// configuration. What cfg contains is entirely up to the backend; e.g.
+// the software HMAC backend expects a JSON-encoded SWConfig (or an
+// empty cfg for a fresh random key), while the factories of the tpm
+// and sgx backends always return an error, since those backends need a
+// live device passed to NewTPM or NewSGX
+type Factory func(cfg []byte) (USIG, error)
+
+var (
+	backendsMu sync.RWMutex
+	backends   = make(map[string]Factory)
+)
+
+// Register makes a USIG backend available under name to later Open
+// calls. It is intended to be called from a backend's init function,
+// following the pattern of database/sql drivers. Register panics if
+// factory is nil or if a backend is already registered under name
+func Register(name string, factory Factory) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+
+	if factory == nil {
+		panic("usig: Register factory is nil")
+	}
+	if _, dup := backends[name]; dup {
+		panic("usig: Register called twice for backend " + name)
+	}
+	backends[name] = factory
+}
+
+// Open constructs a USIG instance using the backend registered under
+// name, passing it cfg unmodified. It returns an error if no backend
+// is registered under name, typically because the backend's package
+// (and its build-tagged registration) was never imported
+func Open(name string, cfg []byte) (USIG, error) {
+	backendsMu.RLock()
+	factory, ok := backends[name]
+	backendsMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("usig: unknown backend %q (forgotten import?)", name)
+	}
+	return factory(cfg)
+}
+
+// Backends returns the names of all currently registered USIG
+// backends, sorted alphabetically
+func Backends() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,84 @@
+package usig
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestOpenUnknownBackend(t *testing.T) {
+	if _, err := Open("no-such-backend", nil); err == nil {
+		t.Error("Open() with unregistered backend succeeded, want error")
+	}
+}
+
+func TestRegisterDuplicatePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Register() with duplicate name did not panic")
+		}
+	}()
+	Register("sw", func(cfg []byte) (USIG, error) { return nil, nil })
+}
+
+func TestBackendsIncludesSW(t *testing.T) {
+	found := false
+	for _, name := range Backends() {
+		if name == "sw" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Backends() = %v, want it to include \"sw\"", Backends())
+	}
+}
+
+func TestSWUSIGCreateAndVerify(t *testing.T) {
+	keyA, err := GenerateSWKey()
+	if err != nil {
+		t.Fatalf("GenerateSWKey() failed: %s", err)
+	}
+	a, err := NewSW(SWConfig{Key: keyA})
+	if err != nil {
+		t.Fatalf("NewSW() failed: %s", err)
+	}
+
+	// b enrolls a's key so it can verify a's UIs, the same way a peer
+	// verifier is enrolled for the tpm and sgx backends
+	b, err := NewSW(SWConfig{PeerKeys: map[string][]byte{
+		fmt.Sprintf("%x", a.ID()): keyA,
+	}})
+	if err != nil {
+		t.Fatalf("NewSW() failed: %s", err)
+	}
+
+	message := []byte("hello")
+	ui, err := a.CreateUI(message)
+	if err != nil {
+		t.Fatalf("CreateUI() failed: %s", err)
+	}
+	if ui.Counter != 1 {
+		t.Errorf("Counter = %d, want 1", ui.Counter)
+	}
+
+	if err := b.VerifyUI(message, ui, a.ID()); err != nil {
+		t.Errorf("VerifyUI() failed for a genuine UI: %s", err)
+	}
+
+	tampered := *ui
+	tampered.Counter++
+	if err := b.VerifyUI(message, &tampered, a.ID()); err == nil {
+		t.Error("VerifyUI() succeeded for a tampered UI, want error")
+	}
+
+	other, err := NewSW(SWConfig{})
+	if err != nil {
+		t.Fatalf("NewSW() failed: %s", err)
+	}
+	if bytes.Equal(a.ID(), other.ID()) {
+		t.Error("two independently generated sw backends produced the same ID")
+	}
+	if err := b.VerifyUI(message, ui, other.ID()); err == nil {
+		t.Error("VerifyUI() succeeded against an unenrolled peer ID, want error")
+	}
+}
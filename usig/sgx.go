@@ -0,0 +1,306 @@
+// Copyright (c) 2018 NEC Laboratories Europe GmbH.
+//
+// Authors: Wenting Li     <wenting.li@neclab.eu>
+//          Sergey Fedorov <sergey.fedorov@neclab.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build sgx
+
+package usig
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sync"
+)
+
+// SGXDevice abstracts the subset of SGX enclave functionality the sgx
+// backend needs, so that this package does not depend on a specific
+// SDK (Intel SGX SDK, Gramine, ...). A concrete implementation lives
+// outside this package and is wired in by whichever command
+// constructs an SGXConfig
+type SGXDevice interface {
+	// Report returns a local SGX report binding reportData to this
+	// enclave's measurement (MRENCLAVE/MRSIGNER)
+	Report(reportData []byte) (report []byte, err error)
+
+	// Seal encrypts data under this enclave's sealing key, so the
+	// monotonic counter survives a restart without leaving the
+	// enclave in the clear
+	Seal(data []byte) (sealed []byte, err error)
+
+	// Unseal decrypts data previously produced by Seal
+	Unseal(sealed []byte) (data []byte, err error)
+
+	// Measurement returns this enclave's MRENCLAVE
+	Measurement() ([]byte, error)
+}
+
+// SGXConfig configures the sgx backend
+type SGXConfig struct {
+	// Device provides access to the enclave runtime
+	Device SGXDevice
+
+	// SealedCounter is the sealed state of the monotonic counter from
+	// a prior run, as carried in the sealed-counter extension of the
+	// UIs returned by CreateUI. Nil starts
+	// the counter fresh at zero, which must only happen on a genuinely
+	// new enrollment; reusing epoch 0 after losing sealed state would
+	// allow a counter to be replayed
+	SealedCounter []byte
+
+	// Epoch identifies this enrollment; it must change whenever
+	// SealedCounter is reset so that peers can tell the counter
+	// sequences apart
+	Epoch uint64
+
+	// PeerMeasurements enrolls the expected MRENCLAVE of peers whose
+	// UIs this instance must be able to verify, keyed by the
+	// hex-encoded usig ID (see ID) of the peer that owns each report
+	PeerMeasurements map[string][]byte
+}
+
+func init() {
+	Register("sgx", newSGXFromJSONConfig)
+}
+
+// sgxUSIG is the in-tree SGX USIG backend. It certifies a UI with a
+// local SGX report over the monotonic counter, sealed between
+// invocations so it survives an enclave restart; VerifyUI checks that
+// report against the issuing peer's enrolled MRENCLAVE
+type sgxUSIG struct {
+	device SGXDevice
+	id     []byte
+	epoch  uint64
+
+	peerMeasurements map[string][]byte
+
+	mu      sync.Mutex
+	counter uint64
+}
+
+// NewSGX creates an SGX USIG backend from cfg
+func NewSGX(cfg SGXConfig) (USIG, error) {
+	measurement, err := cfg.Device.Measurement()
+	if err != nil {
+		return nil, fmt.Errorf("usig: sgx: failed to read enclave measurement: %w", err)
+	}
+	id := sha256.Sum256(measurement)
+
+	counter := uint64(0)
+	if cfg.SealedCounter != nil {
+		data, err := cfg.Device.Unseal(cfg.SealedCounter)
+		if err != nil {
+			return nil, fmt.Errorf("usig: sgx: failed to unseal counter: %w", err)
+		}
+		if len(data) != 8 {
+			return nil, fmt.Errorf("usig: sgx: unsealed counter has unexpected length %d", len(data))
+		}
+		counter = counterFromBytes(data)
+	}
+
+	return &sgxUSIG{
+		device:           cfg.Device,
+		id:               id[:],
+		epoch:            cfg.Epoch,
+		peerMeasurements: cfg.PeerMeasurements,
+		counter:          counter,
+	}, nil
+}
+
+// CertAlgID implements CertAlgProvider
+func (s *sgxUSIG) CertAlgID() CertAlgID {
+	return CertAlgSGX
+}
+
+// CreateUI implements USIG
+func (s *sgxUSIG) CreateUI(message []byte) (*UI, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.counter++
+
+	sealed, err := s.device.Seal(counterToBytes(s.counter))
+	if err != nil {
+		return nil, fmt.Errorf("usig: sgx: failed to seal counter: %w", err)
+	}
+
+	report, err := s.device.Report(attestationData(s.epoch, s.counter, message))
+	if err != nil {
+		return nil, fmt.Errorf("usig: sgx: failed to produce report: %w", err)
+	}
+
+	// The sealed counter is carried alongside the report so that a
+	// restart can resume from SealedCounter without trusting an
+	// external, unsealed value
+	return &UI{
+		Epoch:     s.epoch,
+		Counter:   s.counter,
+		Cert:      report,
+		CertAlgID: CertAlgSGX,
+		Extensions: []UIExtension{
+			{Tag: extTagSealedCounter, Value: sealed},
+		},
+	}, nil
+}
+
+// VerifyUI implements USIG. ui may have been produced by CreateUI or by
+// CreateUIBatch; either is checked in isolation, without needing the
+// rest of a batch
+func (s *sgxUSIG) VerifyUI(message []byte, ui *UI, usigID []byte) error {
+	measurement, ok := s.peerMeasurements[fmt.Sprintf("%x", usigID)]
+	if !ok {
+		return fmt.Errorf("usig: sgx: no enrolled measurement for peer %x", usigID)
+	}
+
+	return VerifySGXReport(measurement, ui, message)
+}
+
+// CreateUIBatch implements BatchUSIG. It advances and seals the
+// counter once per message, as CreateUI would, but reports a single
+// Merkle root over the whole batch instead of reporting every message
+// individually, so the cost of producing an SGX report is amortized
+// across the batch
+func (s *sgxUSIG) CreateUIBatch(messages [][]byte) ([]*UI, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(messages) == 0 {
+		return nil, nil
+	}
+
+	counters := make([]uint64, len(messages))
+	sealed := make([][]byte, len(messages))
+	for i := range messages {
+		s.counter++
+		counters[i] = s.counter
+
+		sb, err := s.device.Seal(counterToBytes(s.counter))
+		if err != nil {
+			return nil, fmt.Errorf("usig: sgx: failed to seal counter: %w", err)
+		}
+		sealed[i] = sb
+	}
+
+	leaves := make([][sha256.Size]byte, len(messages))
+	for i, message := range messages {
+		leaves[i] = merkleLeaf(s.epoch, counters[i], message)
+	}
+	root, paths := merkleRoot(leaves)
+
+	report, err := s.device.Report(root[:])
+	if err != nil {
+		return nil, fmt.Errorf("usig: sgx: failed to produce batch report: %w", err)
+	}
+
+	uis := make([]*UI, len(messages))
+	for i := range messages {
+		// The sealed counter is carried alongside the report, same as
+		// for a single CreateUI, so a restart can resume from
+		// SealedCounter without trusting an external, unsealed value
+		extensions := append(merklePathExtensions(i, paths[i]),
+			UIExtension{Tag: extTagSealedCounter, Value: sealed[i]})
+
+		uis[i] = &UI{
+			Epoch:      s.epoch,
+			Counter:    counters[i],
+			Cert:       report,
+			CertAlgID:  CertAlgSGX,
+			Extensions: extensions,
+		}
+	}
+	return uis, nil
+}
+
+// VerifyUIBatch implements BatchUSIG
+func (s *sgxUSIG) VerifyUIBatch(messages [][]byte, uis []*UI, usigID []byte) error {
+	measurement, ok := s.peerMeasurements[fmt.Sprintf("%x", usigID)]
+	if !ok {
+		return fmt.Errorf("usig: sgx: no enrolled measurement for peer %x", usigID)
+	}
+
+	return verifyMerkleBatch(messages, uis, CertAlgSGX, func(root, report []byte) error {
+		if err := verifyReport(measurement, root, report); err != nil {
+			return fmt.Errorf("usig: sgx: report verification failed: %w", err)
+		}
+		return nil
+	})
+}
+
+// VerifySGXReport performs the same check sgxUSIG.VerifyUI does,
+// without requiring a live SGXDevice: it only needs the peer's
+// enrolled MRENCLAVE. This lets tooling such as cmd/usigctl verify a UI
+// offline against a genesis bundle
+func VerifySGXReport(measurement []byte, ui *UI, message []byte) error {
+	if ui.CertAlgID != CertAlgSGX {
+		return fmt.Errorf("usig: sgx: UI certificate algorithm %d does not match sgx backend", ui.CertAlgID)
+	}
+
+	data, err := quotedData(ui, message)
+	if err != nil {
+		return fmt.Errorf("usig: sgx: %w", err)
+	}
+
+	if err := verifyReport(measurement, data, ui.Cert); err != nil {
+		return fmt.Errorf("usig: sgx: report verification failed: %w", err)
+	}
+
+	return nil
+}
+
+// ID implements USIG. It is derived from the enclave measurement
+// rather than being supplied externally, so a peer's identity cannot
+// be forged without running the measured enclave
+func (s *sgxUSIG) ID() []byte {
+	return s.id
+}
+
+// extTagSealedCounter tags the UI.Extensions entry carrying the sealed
+// counter alongside an SGX report
+const extTagSealedCounter byte = 1
+
+func counterToBytes(counter uint64) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, counter)
+	return b
+}
+
+func counterFromBytes(b []byte) uint64 {
+	return binary.LittleEndian.Uint64(b)
+}
+
+// verifyReport checks that report is a well-formed SGX report binding
+// reportData to measurement. The actual quote/report structure parsing
+// and platform-level quote verification (e.g. against Intel's quoting
+// enclave or a DCAP collateral chain) is SDK-specific and left to the
+// SGXDevice implementation's accompanying verifier; here we only check
+// the two fields this package controls
+func verifyReport(measurement, reportData, report []byte) error {
+	if len(report) < len(measurement)+len(reportData) {
+		return fmt.Errorf("report too short to contain measurement and report data")
+	}
+	if !bytes.Equal(report[:len(measurement)], measurement) {
+		return fmt.Errorf("measurement mismatch")
+	}
+	if !bytes.Equal(report[len(measurement):len(measurement)+len(reportData)], reportData) {
+		return fmt.Errorf("report data mismatch")
+	}
+	return nil
+}
+
+func newSGXFromJSONConfig(cfg []byte) (USIG, error) {
+	return nil, fmt.Errorf("usig: sgx: backend requires a live SGXDevice; construct it with NewSGX instead of Open(%q, ...)", "sgx")
+}
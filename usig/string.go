@@ -0,0 +1,93 @@
+// Copyright (c) 2018 NEC Laboratories Europe GmbH.
+//
+// Authors: Wenting Li     <wenting.li@neclab.eu>
+//          Sergey Fedorov <sergey.fedorov@neclab.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package usig
+
+import (
+	"bytes"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// crockfordEncoding implements Crockford's base32 alphabet: upper-case,
+// no padding, and omitting I/L/O/U to avoid visual confusion with
+// 1/1/0/V. It preserves the lexical order of the byte string it encodes
+var crockfordEncoding = base32.NewEncoding("0123456789ABCDEFGHJKMNPQRSTVWXYZ").WithPadding(base32.NoPadding)
+
+// String returns a canonical, human-readable encoding of ui, modeled on
+// ULID: the leading 16 bytes encode Epoch||Counter big-endian so that
+// the lexical order of the returned string matches (Epoch, Counter)
+// order across UIs, giving operators a sortable, URL-safe,
+// copy-pasteable form for log correlation. The remaining characters
+// are a uvarint-length-prefixed encoding of Cert, so the round trip
+// with MarshalBinary/UnmarshalBinary is lossless for any certificate
+// length. It does not carry CertAlgID or Extensions
+func (ui *UI) String() string {
+	return crockfordEncoding.EncodeToString(ui.sortableBytes())
+}
+
+// ParseUI parses the canonical encoding produced by UI.String. Parsing
+// is case-insensitive, as Crockford's base32 specifies
+func ParseUI(s string) (*UI, error) {
+	data, err := crockfordEncoding.DecodeString(strings.ToUpper(s))
+	if err != nil {
+		return nil, fmt.Errorf("usig: invalid UI string: %w", err)
+	}
+	if len(data) < 16 {
+		return nil, fmt.Errorf("usig: invalid UI string: decodes to %d bytes, want at least 16", len(data))
+	}
+
+	ui := &UI{
+		Epoch:   binary.BigEndian.Uint64(data[0:8]),
+		Counter: binary.BigEndian.Uint64(data[8:16]),
+	}
+
+	rest := bytes.NewBuffer(data[16:])
+	certLen, err := binary.ReadUvarint(rest)
+	if err != nil {
+		return nil, fmt.Errorf("usig: invalid UI string: %w", err)
+	}
+	if uint64(rest.Len()) < certLen {
+		return nil, fmt.Errorf("usig: invalid UI string: truncated certificate: want %d bytes, have %d", certLen, rest.Len())
+	}
+	ui.Cert = make([]byte, certLen)
+	if _, err := rest.Read(ui.Cert); err != nil {
+		return nil, err
+	}
+	if rest.Len() != 0 {
+		return nil, fmt.Errorf("usig: invalid UI string: %d trailing bytes after certificate", rest.Len())
+	}
+
+	return ui, nil
+}
+
+// sortableBytes lays out ui as Epoch||Counter big-endian followed by a
+// uvarint-length-prefixed Cert, the byte string String encodes
+func (ui *UI) sortableBytes() []byte {
+	buf := make([]byte, 16, 16+binary.MaxVarintLen64+len(ui.Cert))
+	binary.BigEndian.PutUint64(buf[0:8], ui.Epoch)
+	binary.BigEndian.PutUint64(buf[8:16], ui.Counter)
+
+	lenBuf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(lenBuf, uint64(len(ui.Cert)))
+	buf = append(buf, lenBuf[:n]...)
+	buf = append(buf, ui.Cert...)
+
+	return buf
+}
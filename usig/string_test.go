@@ -0,0 +1,90 @@
+package usig
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestUIStringRoundTrip(t *testing.T) {
+	ui := &UI{Epoch: 7, Counter: 42, Cert: []byte("some-certificate-bytes")}
+
+	got, err := ParseUI(ui.String())
+	if err != nil {
+		t.Fatalf("ParseUI() failed: %s", err)
+	}
+	if got.Epoch != ui.Epoch || got.Counter != ui.Counter || !bytes.Equal(got.Cert, ui.Cert) {
+		t.Errorf("ParseUI(String()) = %+v, want %+v", got, ui)
+	}
+}
+
+func TestUIStringRoundTripMatchesMarshalBinaryFields(t *testing.T) {
+	ui := &UI{Epoch: 1, Counter: 2, Cert: []byte{1, 2, 3, 4, 5}, CertAlgID: CertAlgHMAC}
+
+	data, err := ui.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() failed: %s", err)
+	}
+	var viaBinary UI
+	if err := viaBinary.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary() failed: %s", err)
+	}
+
+	viaString, err := ParseUI(ui.String())
+	if err != nil {
+		t.Fatalf("ParseUI() failed: %s", err)
+	}
+
+	if viaString.Epoch != viaBinary.Epoch || viaString.Counter != viaBinary.Counter || !bytes.Equal(viaString.Cert, viaBinary.Cert) {
+		t.Errorf("ParseUI(String()) = %+v, want it to agree with UnmarshalBinary() = %+v", viaString, viaBinary)
+	}
+}
+
+func TestUIStringCaseInsensitive(t *testing.T) {
+	ui := &UI{Epoch: 9, Counter: 3, Cert: []byte("x")}
+	s := ui.String()
+
+	got, err := ParseUI(strings.ToLower(s))
+	if err != nil {
+		t.Fatalf("ParseUI() of lower-cased string failed: %s", err)
+	}
+	if got.Epoch != ui.Epoch || got.Counter != ui.Counter {
+		t.Errorf("ParseUI(lower-cased) = %+v, want Epoch=%d Counter=%d", got, ui.Epoch, ui.Counter)
+	}
+}
+
+func TestParseUIRejectsInvalidCharacters(t *testing.T) {
+	if _, err := ParseUI("not-valid-crockford-base32!!"); err == nil {
+		t.Error("ParseUI() with invalid characters succeeded, want error")
+	}
+}
+
+func TestUIStringSortOrderMatchesEpochCounter(t *testing.T) {
+	uis := []*UI{
+		{Epoch: 1, Counter: 5, Cert: []byte("a")},
+		{Epoch: 0, Counter: 1<<64 - 1, Cert: []byte("b")}, // largest counter of a lower epoch
+		{Epoch: 2, Counter: 0, Cert: []byte("c")},
+		{Epoch: 1, Counter: 1, Cert: []byte("d")},
+	}
+
+	strs := make([]string, len(uis))
+	for i, ui := range uis {
+		strs[i] = ui.String()
+	}
+	sort.Strings(strs)
+
+	for i := 1; i < len(strs); i++ {
+		prev, err := ParseUI(strs[i-1])
+		if err != nil {
+			t.Fatalf("ParseUI() failed: %s", err)
+		}
+		cur, err := ParseUI(strs[i])
+		if err != nil {
+			t.Fatalf("ParseUI() failed: %s", err)
+		}
+		if prev.Epoch > cur.Epoch || (prev.Epoch == cur.Epoch && prev.Counter > cur.Counter) {
+			t.Errorf("sorted strings out of (Epoch, Counter) order: %+v before %+v", prev, cur)
+		}
+	}
+}
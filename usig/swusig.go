@@ -0,0 +1,184 @@
+// Copyright (c) 2018 NEC Laboratories Europe GmbH.
+//
+// Authors: Wenting Li     <wenting.li@neclab.eu>
+//          Sergey Fedorov <sergey.fedorov@neclab.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package usig
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+func init() {
+	Register("sw", newSWFromJSONConfig)
+}
+
+// SWConfig configures the sw backend
+type SWConfig struct {
+	// Key is this instance's shared HMAC key. If nil, a fresh random
+	// key is generated
+	Key []byte `json:"key"`
+
+	// PeerKeys enrolls the shared HMAC key of peers whose UIs this
+	// instance must be able to verify, keyed by the hex-encoded usig
+	// ID (see ID) of the peer that owns each key. Because HMAC is
+	// symmetric, enrolling a peer's key here gives this instance the
+	// same power to forge that peer's UIs as the peer itself, which is
+	// why sw is suitable for development and testing but not for a
+	// real deployment
+	PeerKeys map[string][]byte `json:"peer_keys,omitempty"`
+}
+
+func newSWFromJSONConfig(cfg []byte) (USIG, error) {
+	if len(cfg) == 0 {
+		return NewSW(SWConfig{})
+	}
+
+	var parsed SWConfig
+	if err := json.Unmarshal(cfg, &parsed); err != nil {
+		return nil, fmt.Errorf("usig: sw: invalid config: %w", err)
+	}
+	return NewSW(parsed)
+}
+
+// swUSIG is the in-tree software USIG backend. It authenticates UIs
+// with HMAC-SHA256 under a locally held key rather than a tamper-proof
+// hardware root of trust, making it suitable for development and
+// testing but not for deployments that need the guarantees a TPM or
+// SGX backend provides
+type swUSIG struct {
+	key   []byte
+	id    []byte
+	epoch uint64
+
+	peerKeys map[string][]byte
+
+	mu      sync.Mutex
+	counter uint64
+}
+
+// GenerateSWKey returns fresh random key material suitable for
+// SWConfig.Key. It is exposed so identity material can be generated
+// once (e.g. by cmd/usigctl) and persisted for later use, rather than
+// regenerating a key on every process start
+func GenerateSWKey() ([]byte, error) {
+	key := make([]byte, sha256.Size)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("usig: failed to generate HMAC key: %w", err)
+	}
+	return key, nil
+}
+
+// NewSW creates a software USIG backend from cfg. If cfg.Key is nil, a
+// fresh random key is generated. It is registered under the backend
+// name "sw"
+func NewSW(cfg SWConfig) (USIG, error) {
+	key := cfg.Key
+	if key == nil {
+		var err error
+		if key, err = GenerateSWKey(); err != nil {
+			return nil, err
+		}
+	}
+
+	epochBytes := make([]byte, 8)
+	if _, err := rand.Read(epochBytes); err != nil {
+		return nil, fmt.Errorf("usig: failed to generate epoch: %w", err)
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("usig-sw-identity"))
+
+	return &swUSIG{
+		key:      key,
+		id:       mac.Sum(nil),
+		epoch:    binary.LittleEndian.Uint64(epochBytes),
+		peerKeys: cfg.PeerKeys,
+	}, nil
+}
+
+// CertAlgID implements CertAlgProvider
+func (s *swUSIG) CertAlgID() CertAlgID {
+	return CertAlgHMAC
+}
+
+// CreateUI implements USIG
+func (s *swUSIG) CreateUI(message []byte) (*UI, error) {
+	s.mu.Lock()
+	s.counter++
+	counter := s.counter
+	s.mu.Unlock()
+
+	return &UI{
+		Epoch:     s.epoch,
+		Counter:   counter,
+		Cert:      swMAC(s.key, message, s.epoch, counter),
+		CertAlgID: CertAlgHMAC,
+	}, nil
+}
+
+// VerifyUI implements USIG. It can only verify a UI whose claimed
+// identity had its key enrolled in the PeerKeys this instance was
+// constructed with (see NewSW), mirroring how tpmUSIG and sgxUSIG can
+// only verify a UI from a peer enrolled in their own peer table
+func (s *swUSIG) VerifyUI(message []byte, ui *UI, usigID []byte) error {
+	key, ok := s.peerKeys[fmt.Sprintf("%x", usigID)]
+	if !ok {
+		return fmt.Errorf("usig: sw: no enrolled key for peer %x", usigID)
+	}
+
+	return VerifySWMAC(key, ui, message)
+}
+
+// VerifySWMAC performs the same check swUSIG.VerifyUI does, without
+// requiring a full swUSIG instance: it only needs the peer's enrolled
+// shared HMAC key. This lets tooling such as cmd/usigctl verify a UI
+// offline against a genesis bundle
+func VerifySWMAC(key []byte, ui *UI, message []byte) error {
+	if ui.CertAlgID != CertAlgHMAC {
+		return fmt.Errorf("usig: sw: UI certificate algorithm %d does not match sw backend", ui.CertAlgID)
+	}
+
+	expected := swMAC(key, message, ui.Epoch, ui.Counter)
+	if !hmac.Equal(ui.Cert, expected) {
+		return fmt.Errorf("usig: sw: invalid UI certificate")
+	}
+
+	return nil
+}
+
+// ID implements USIG
+func (s *swUSIG) ID() []byte {
+	return s.id
+}
+
+func swMAC(key, message []byte, epoch, counter uint64) []byte {
+	mac := hmac.New(sha256.New, key)
+
+	var hdr [16]byte
+	binary.LittleEndian.PutUint64(hdr[0:8], epoch)
+	binary.LittleEndian.PutUint64(hdr[8:16], counter)
+
+	mac.Write(hdr[:])
+	mac.Write(message)
+
+	return mac.Sum(nil)
+}
@@ -0,0 +1,293 @@
+// Copyright (c) 2018 NEC Laboratories Europe GmbH.
+//
+// Authors: Wenting Li     <wenting.li@neclab.eu>
+//          Sergey Fedorov <sergey.fedorov@neclab.eu>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build tpm
+
+package usig
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+)
+
+// TPMDevice abstracts the subset of TPM 2.0 functionality the tpm
+// backend needs, so that this package does not depend on a specific
+// low-level TPM transport (device file, simulator, resource manager
+// socket, ...). A concrete implementation lives outside this package
+// and is wired in by whichever command constructs a TPMConfig
+type TPMDevice interface {
+	// ReadNVCounter returns the current value of the monotonic NV
+	// counter at nvIndex without incrementing it
+	ReadNVCounter(nvIndex uint32) (uint64, error)
+
+	// IncrementNVCounter increments the monotonic NV counter at
+	// nvIndex and returns its new value
+	IncrementNVCounter(nvIndex uint32) (uint64, error)
+
+	// Quote returns a TPM quote over data, signed by the attestation
+	// key at akHandle
+	Quote(akHandle uint32, data []byte) (quote []byte, err error)
+
+	// AKPublic returns the DER-encoded public key of the attestation
+	// key at akHandle
+	AKPublic(akHandle uint32) ([]byte, error)
+}
+
+// TPMConfig configures the tpm backend
+type TPMConfig struct {
+	// Device provides access to the physical or simulated TPM
+	Device TPMDevice
+
+	// EpochNVIndex is the NV index holding this instance's epoch. It
+	// is read once at construction and is only expected to change
+	// across a re-enrollment, distinguishing counters reset that way
+	EpochNVIndex uint32
+
+	// CounterNVIndex is the NV index of the monotonic counter
+	// dedicated to this USIG instance
+	CounterNVIndex uint32
+
+	// AKHandle is the handle of the enrolled attestation key used to
+	// quote the counter
+	AKHandle uint32
+
+	// PeerAKCerts enrolls the AK certificates of peers whose UIs this
+	// instance must be able to verify, keyed by the hex-encoded usig
+	// ID (see ID) of the peer that owns each certificate
+	PeerAKCerts map[string]*x509.Certificate
+}
+
+func init() {
+	Register("tpm", newTPMFromJSONConfig)
+}
+
+// tpmUSIG is the in-tree TPM 2.0 USIG backend. It certifies a UI with a
+// TPM quote over the monotonic NV counter CounterNVIndex, signed by an
+// enrolled attestation key; VerifyUI checks that quote against the
+// issuing peer's enrolled AK certificate
+type tpmUSIG struct {
+	cfg   TPMConfig
+	id    []byte
+	epoch uint64
+}
+
+// NewTPM creates a TPM USIG backend from cfg
+func NewTPM(cfg TPMConfig) (USIG, error) {
+	akPub, err := cfg.Device.AKPublic(cfg.AKHandle)
+	if err != nil {
+		return nil, fmt.Errorf("usig: tpm: failed to read AK public key: %w", err)
+	}
+	id := sha256.Sum256(akPub)
+
+	epoch, err := cfg.Device.ReadNVCounter(cfg.EpochNVIndex)
+	if err != nil {
+		return nil, fmt.Errorf("usig: tpm: failed to read epoch counter: %w", err)
+	}
+
+	return &tpmUSIG{cfg: cfg, id: id[:], epoch: epoch}, nil
+}
+
+// CertAlgID implements CertAlgProvider
+func (t *tpmUSIG) CertAlgID() CertAlgID {
+	return CertAlgTPM
+}
+
+// CreateUI implements USIG
+func (t *tpmUSIG) CreateUI(message []byte) (*UI, error) {
+	counter, err := t.cfg.Device.IncrementNVCounter(t.cfg.CounterNVIndex)
+	if err != nil {
+		return nil, fmt.Errorf("usig: tpm: failed to increment NV counter: %w", err)
+	}
+
+	quote, err := t.cfg.Device.Quote(t.cfg.AKHandle, attestationData(t.epoch, counter, message))
+	if err != nil {
+		return nil, fmt.Errorf("usig: tpm: failed to quote NV counter: %w", err)
+	}
+
+	return &UI{
+		Epoch:     t.epoch,
+		Counter:   counter,
+		Cert:      quote,
+		CertAlgID: CertAlgTPM,
+	}, nil
+}
+
+// VerifyUI implements USIG. ui may have been produced by CreateUI or by
+// CreateUIBatch; either is checked in isolation, without needing the
+// rest of a batch
+func (t *tpmUSIG) VerifyUI(message []byte, ui *UI, usigID []byte) error {
+	cert, ok := t.cfg.PeerAKCerts[fmt.Sprintf("%x", usigID)]
+	if !ok {
+		return fmt.Errorf("usig: tpm: no enrolled AK certificate for peer %x", usigID)
+	}
+
+	return VerifyTPMQuote(cert, ui, message)
+}
+
+// CreateUIBatch implements BatchUSIG. It increments the NV counter once
+// per message, as CreateUI would, but quotes a single Merkle root over
+// the whole batch instead of quoting every message individually, so the
+// cost of a TPM quote is amortized across the batch
+func (t *tpmUSIG) CreateUIBatch(messages [][]byte) ([]*UI, error) {
+	if len(messages) == 0 {
+		return nil, nil
+	}
+
+	counters := make([]uint64, len(messages))
+	for i := range messages {
+		counter, err := t.cfg.Device.IncrementNVCounter(t.cfg.CounterNVIndex)
+		if err != nil {
+			return nil, fmt.Errorf("usig: tpm: failed to increment NV counter: %w", err)
+		}
+		counters[i] = counter
+	}
+
+	leaves := make([][sha256.Size]byte, len(messages))
+	for i, message := range messages {
+		leaves[i] = merkleLeaf(t.epoch, counters[i], message)
+	}
+	root, paths := merkleRoot(leaves)
+
+	quote, err := t.cfg.Device.Quote(t.cfg.AKHandle, root[:])
+	if err != nil {
+		return nil, fmt.Errorf("usig: tpm: failed to quote batch root: %w", err)
+	}
+
+	uis := make([]*UI, len(messages))
+	for i := range messages {
+		uis[i] = &UI{
+			Epoch:      t.epoch,
+			Counter:    counters[i],
+			Cert:       quote,
+			CertAlgID:  CertAlgTPM,
+			Extensions: merklePathExtensions(i, paths[i]),
+		}
+	}
+	return uis, nil
+}
+
+// VerifyUIBatch implements BatchUSIG
+func (t *tpmUSIG) VerifyUIBatch(messages [][]byte, uis []*UI, usigID []byte) error {
+	cert, ok := t.cfg.PeerAKCerts[fmt.Sprintf("%x", usigID)]
+	if !ok {
+		return fmt.Errorf("usig: tpm: no enrolled AK certificate for peer %x", usigID)
+	}
+
+	return verifyMerkleBatch(messages, uis, CertAlgTPM, func(root, quote []byte) error {
+		if err := verifySignature(cert.PublicKey, root, quote); err != nil {
+			return fmt.Errorf("usig: tpm: quote verification failed: %w", err)
+		}
+		return nil
+	})
+}
+
+// VerifyTPMQuote performs the same check tpmUSIG.VerifyUI does, without
+// requiring a live TPMDevice: it only needs the peer's enrolled AK
+// certificate. This lets tooling such as cmd/usigctl verify a UI
+// offline against a genesis bundle
+func VerifyTPMQuote(cert *x509.Certificate, ui *UI, message []byte) error {
+	if ui.CertAlgID != CertAlgTPM {
+		return fmt.Errorf("usig: tpm: UI certificate algorithm %d does not match tpm backend", ui.CertAlgID)
+	}
+
+	data, err := quotedData(ui, message)
+	if err != nil {
+		return fmt.Errorf("usig: tpm: %w", err)
+	}
+
+	if err := verifySignature(cert.PublicKey, data, ui.Cert); err != nil {
+		return fmt.Errorf("usig: tpm: quote verification failed: %w", err)
+	}
+
+	return nil
+}
+
+// ID implements USIG. It is derived from the enrolled attestation key
+// rather than being supplied externally, so a peer's identity cannot
+// be forged without possessing that key
+func (t *tpmUSIG) ID() []byte {
+	return t.id
+}
+
+// verifySignature verifies sig over data using pub, dispatching on the
+// concrete key type of an enrolled AK certificate
+func verifySignature(pub interface{}, data, sig []byte) error {
+	digest := sha256.Sum256(data)
+
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig)
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, digest[:], sig) {
+			return fmt.Errorf("ecdsa signature verification failed")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported AK public key type %T", pub)
+	}
+}
+
+// TPMJSONConfig is the on-disk JSON representation of the plain-data
+// portion of a TPMConfig. Device construction itself is out of scope
+// here: the process wiring this config together opens the appropriate
+// TPMDevice itself and calls NewTPM directly; the registry factory
+// does not construct a tpm backend at all
+type TPMJSONConfig struct {
+	EpochNVIndex   uint32            `json:"epoch_nv_index"`
+	CounterNVIndex uint32            `json:"counter_nv_index"`
+	AKHandle       uint32            `json:"ak_handle"`
+	PeerAKCertsPEM map[string]string `json:"peer_ak_certs"`
+}
+
+func newTPMFromJSONConfig(cfg []byte) (USIG, error) {
+	return nil, fmt.Errorf("usig: tpm: backend requires a live TPMDevice; construct it with NewTPM instead of Open(%q, ...)", "tpm")
+}
+
+// ParseTPMJSONConfig decodes the plain-data portion of a tpm backend
+// configuration, for callers that assemble the TPMDevice themselves
+// and only need help parsing enrolled peer certs
+func ParseTPMJSONConfig(raw []byte) (TPMJSONConfig, error) {
+	var cfg TPMJSONConfig
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return TPMJSONConfig{}, fmt.Errorf("usig: tpm: invalid config: %w", err)
+	}
+	return cfg, nil
+}
+
+// DecodePeerAKCerts parses the PEM-encoded AK certificates of a
+// TPMJSONConfig into the map expected by TPMConfig.PeerAKCerts
+func DecodePeerAKCerts(pemByPeerID map[string]string) (map[string]*x509.Certificate, error) {
+	certs := make(map[string]*x509.Certificate, len(pemByPeerID))
+	for peerID, pemCert := range pemByPeerID {
+		block, _ := pem.Decode([]byte(pemCert))
+		if block == nil {
+			return nil, fmt.Errorf("usig: tpm: no PEM block found for peer %s", peerID)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("usig: tpm: invalid AK certificate for peer %s: %w", peerID, err)
+		}
+		certs[peerID] = cert
+	}
+	return certs, nil
+}
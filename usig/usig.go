@@ -20,6 +20,7 @@ package usig
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 )
 
 // USIG (Unique Sequential Identifier Generator) is a tamper-proof
@@ -32,13 +33,142 @@ type USIG interface {
 	CreateUI(message []byte) (*UI, error)
 
 	// VerifyUI verifies if the UI is valid for the message and
-	// was generated by the specified USIG identity
+	// was generated by the specified USIG identity. Implementations
+	// that also satisfy CertAlgProvider must reject a UI whose
+	// CertAlgID does not match their own before attempting any
+	// cryptographic verification of Cert
 	VerifyUI(message []byte, ui *UI, usigID []byte) error
 
 	// ID returns the identity of this USIG instance
 	ID() []byte
 }
 
+// BatchUSIG is optionally implemented by a USIG backend that can
+// produce or verify many UIs more cheaply as a batch than one at a
+// time. It matters most for hardware-rooted backends such as tpm and
+// sgx, where each signing operation can cost milliseconds: their
+// CreateUIBatch commits a single attestation to a Merkle root over the
+// batch instead of attesting every message individually, and their
+// VerifyUIBatch checks a whole batch against that shared attestation in
+// one pass. Backends that do not implement BatchUSIG are still usable
+// through CreateUIBatch and VerifyUIBatch, the package-level functions
+// below, which fall back to looping over CreateUI and VerifyUI
+type BatchUSIG interface {
+	USIG
+
+	// CreateUIBatch returns a UI for each of messages, in order,
+	// assigning consecutive counters. Implementations that batch the
+	// underlying attestation must still produce one *UI per message,
+	// with enough information in it (e.g. a Merkle sibling path in
+	// Extensions) for VerifyUI to check that UI in isolation later
+	CreateUIBatch(messages [][]byte) ([]*UI, error)
+
+	// VerifyUIBatch verifies that uis[i] is valid for messages[i] and
+	// was generated by usigID, for every i. It never accepts a UI that
+	// VerifyUI would reject. The tpm and sgx implementations accept only
+	// UIs that all come from one CreateUIBatch call: UIs created one at
+	// a time by CreateUI, or taken from different batches, are rejected
+	// even where VerifyUI would accept each of them
+	VerifyUIBatch(messages [][]byte, uis []*UI, usigID []byte) error
+}
+
+// CreateUIBatch returns a UI for each of messages, using u's own
+// CreateUIBatch if it implements BatchUSIG, or else looping over
+// CreateUI one message at a time. Callers that assign UIs to a batch of
+// requests queued in the same scheduler tick should go through this
+// function rather than calling CreateUI in a loop themselves, so that a
+// batching backend gets the chance to amortize its signing cost
+func CreateUIBatch(u USIG, messages [][]byte) ([]*UI, error) {
+	if b, ok := u.(BatchUSIG); ok {
+		return b.CreateUIBatch(messages)
+	}
+
+	uis := make([]*UI, len(messages))
+	for i, message := range messages {
+		ui, err := u.CreateUI(message)
+		if err != nil {
+			return nil, fmt.Errorf("usig: batch: message %d: %w", i, err)
+		}
+		uis[i] = ui
+	}
+	return uis, nil
+}
+
+// VerifyUIBatch verifies that uis[i] is valid for messages[i] and was
+// generated by usigID, for every i, using u's own VerifyUIBatch if it
+// implements BatchUSIG, or else looping over VerifyUI one message at a
+// time
+func VerifyUIBatch(u USIG, messages [][]byte, uis []*UI, usigID []byte) error {
+	if b, ok := u.(BatchUSIG); ok {
+		return b.VerifyUIBatch(messages, uis, usigID)
+	}
+
+	if len(messages) != len(uis) {
+		return fmt.Errorf("usig: batch: %d messages but %d UIs", len(messages), len(uis))
+	}
+	for i := range messages {
+		if err := u.VerifyUI(messages[i], uis[i], usigID); err != nil {
+			return fmt.Errorf("usig: batch: message %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// CertAlgProvider is optionally implemented by a USIG backend to
+// advertise the certificate scheme it produces. It lets peers reject a
+// UI generated by an incompatible backend (e.g. a TPM quote presented
+// to a software HMAC verifier) before running any certificate
+// verification, and lets them negotiate a compatible backend at join
+// time.
+type CertAlgProvider interface {
+	// CertAlgID returns the identifier of the certificate algorithm
+	// this USIG instance uses when creating UIs
+	CertAlgID() CertAlgID
+}
+
+// CertAlgID identifies the algorithm used to produce a UI's Cert, so
+// that UIs created by different USIG implementations can be told apart
+// on the wire
+type CertAlgID byte
+
+// Certificate algorithms recognized by this package. Backends outside
+// this package should define their own constants starting at
+// CertAlgReserved to avoid collisions.
+const (
+	// CertAlgUnknown is the zero value and never assigned to a real UI
+	CertAlgUnknown CertAlgID = iota
+
+	// CertAlgHMAC identifies the in-tree software HMAC backend
+	CertAlgHMAC
+
+	// CertAlgTPM identifies the in-tree TPM 2.0 backend, whose Cert is
+	// a TPM quote over a monotonic NV counter
+	CertAlgTPM
+
+	// CertAlgSGX identifies the in-tree SGX backend, whose Cert is an
+	// enclave report covering a sealed monotonic counter
+	CertAlgSGX
+
+	// CertAlgReserved marks the start of the range available to
+	// out-of-tree USIG backends
+	CertAlgReserved = 0x80
+)
+
+// Wire format versions for UI.MarshalBinary/UnmarshalBinary
+const (
+	// uiVersion0 is the legacy, unversioned wire format:
+	// Epoch(8) || Counter(8) || Cert(rest). It carries no version or
+	// algorithm tag and cannot be distinguished from uiVersion1 by
+	// inspection, so it is only ever decoded by UnmarshalUIV0, used
+	// to migrate already-persisted UIs, and never produced
+	uiVersion0 = 0
+
+	// uiVersion1 is the current, framed wire format: Version(1) ||
+	// CertAlgID(1) || Epoch(8) || Counter(8) || uvarint-length-prefixed
+	// Cert || zero or more TLV extensions
+	uiVersion1 = 1
+)
+
 // UI is a unique identifier assigned to a message by a USIG
 type UI struct {
 	// Unique value for each USIG instance
@@ -50,47 +180,153 @@ type UI struct {
 	// Certificate created by a tamper-proof component of the USIG
 	// that certifies the counter assigned to a particular message
 	Cert []byte
+
+	// CertAlgID identifies the algorithm that produced Cert. It is
+	// populated by UnmarshalBinary and should be set by a USIG
+	// backend before marshaling a UI it created
+	CertAlgID CertAlgID
+
+	// Extensions carries forward-compatible TLV fields appended
+	// after Cert. Decoders that do not recognize a tag preserve it
+	// here rather than rejecting the UI, so that a future version can
+	// add fields without breaking older peers
+	Extensions []UIExtension
+}
+
+// UIExtension is a single length-prefixed TLV element appended after
+// Cert in the framed wire format
+type UIExtension struct {
+	Tag   byte
+	Value []byte
 }
 
-// MarshalBinary marshals UI to byte array
+// MarshalBinary marshals UI to byte array using the current (v1) framed
+// wire format
 func (ui *UI) MarshalBinary() ([]byte, error) {
 	buf := new(bytes.Buffer)
 
-	// First, marshal the epoch and counter
-	err := binary.Write(buf, binary.LittleEndian, ui.Epoch)
-	if err != nil {
+	if err := buf.WriteByte(uiVersion1); err != nil {
 		return nil, err
 	}
-	err = binary.Write(buf, binary.LittleEndian, ui.Counter)
-	if err != nil {
+	if err := buf.WriteByte(byte(ui.CertAlgID)); err != nil {
 		return nil, err
 	}
 
-	// Then, append the USIG certificate bytes
-	err = binary.Write(buf, binary.LittleEndian, ui.Cert)
-	if err != nil {
+	// Epoch and counter remain fixed-width, as in the legacy format
+	if err := binary.Write(buf, binary.LittleEndian, ui.Epoch); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(buf, binary.LittleEndian, ui.Counter); err != nil {
 		return nil, err
 	}
 
+	// The certificate is now length-prefixed so that TLV extensions
+	// can follow it unambiguously
+	lenBuf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(lenBuf, uint64(len(ui.Cert)))
+	if _, err := buf.Write(lenBuf[:n]); err != nil {
+		return nil, err
+	}
+	if _, err := buf.Write(ui.Cert); err != nil {
+		return nil, err
+	}
+
+	for _, ext := range ui.Extensions {
+		if err := buf.WriteByte(ext.Tag); err != nil {
+			return nil, err
+		}
+		n := binary.PutUvarint(lenBuf, uint64(len(ext.Value)))
+		if _, err := buf.Write(lenBuf[:n]); err != nil {
+			return nil, err
+		}
+		if _, err := buf.Write(ext.Value); err != nil {
+			return nil, err
+		}
+	}
+
 	return buf.Bytes(), nil
 }
 
-// UnmarshalBinary unmarshals byte array to UI
+// UnmarshalBinary unmarshals byte array produced by MarshalBinary to
+// UI. Only the current (v1) framed wire format is accepted; already
+// persisted, pre-framing UIs should be migrated with UnmarshalUIV0
+// instead
 func (ui *UI) UnmarshalBinary(in []byte) error {
 	buf := bytes.NewBuffer(in)
 
-	// First, unmarshal the epoch and counter
-	err := binary.Read(buf, binary.LittleEndian, &ui.Epoch)
+	version, err := buf.ReadByte()
 	if err != nil {
 		return err
 	}
-	err = binary.Read(buf, binary.LittleEndian, &ui.Counter)
+	if version != uiVersion1 {
+		return fmt.Errorf("usig: unsupported UI wire format version %d", version)
+	}
+
+	algID, err := buf.ReadByte()
 	if err != nil {
 		return err
 	}
+	ui.CertAlgID = CertAlgID(algID)
 
-	// The rest are the USIG certificate bytes
-	ui.Cert = buf.Bytes()
+	if err := binary.Read(buf, binary.LittleEndian, &ui.Epoch); err != nil {
+		return err
+	}
+	if err := binary.Read(buf, binary.LittleEndian, &ui.Counter); err != nil {
+		return err
+	}
+
+	certLen, err := binary.ReadUvarint(buf)
+	if err != nil {
+		return err
+	}
+	if uint64(buf.Len()) < certLen {
+		return fmt.Errorf("usig: truncated UI certificate: want %d bytes, have %d", certLen, buf.Len())
+	}
+	ui.Cert = make([]byte, certLen)
+	if _, err := buf.Read(ui.Cert); err != nil {
+		return err
+	}
+
+	ui.Extensions = nil
+	for buf.Len() > 0 {
+		tag, err := buf.ReadByte()
+		if err != nil {
+			return err
+		}
+		valLen, err := binary.ReadUvarint(buf)
+		if err != nil {
+			return err
+		}
+		if uint64(buf.Len()) < valLen {
+			return fmt.Errorf("usig: truncated UI extension %d: want %d bytes, have %d", tag, valLen, buf.Len())
+		}
+		val := make([]byte, valLen)
+		if _, err := buf.Read(val); err != nil {
+			return err
+		}
+		ui.Extensions = append(ui.Extensions, UIExtension{Tag: tag, Value: val})
+	}
 
 	return nil
 }
+
+// UnmarshalUIV0 decodes a UI from the legacy, unversioned wire format
+// (Epoch(8) || Counter(8) || Cert(rest)) used before framing was
+// introduced. It exists solely to migrate already-persisted UIs; newly
+// created UIs are always marshaled in the current framed format
+func UnmarshalUIV0(in []byte) (*UI, error) {
+	buf := bytes.NewBuffer(in)
+
+	ui := &UI{CertAlgID: CertAlgHMAC}
+
+	if err := binary.Read(buf, binary.LittleEndian, &ui.Epoch); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(buf, binary.LittleEndian, &ui.Counter); err != nil {
+		return nil, err
+	}
+
+	ui.Cert = buf.Bytes()
+
+	return ui, nil
+}
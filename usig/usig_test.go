@@ -0,0 +1,110 @@
+package usig
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestUIRoundTrip(t *testing.T) {
+	ui := &UI{
+		Epoch:     42,
+		Counter:   7,
+		Cert:      []byte("deadbeef"),
+		CertAlgID: CertAlgHMAC,
+		Extensions: []UIExtension{
+			{Tag: 1, Value: []byte("ext-value")},
+		},
+	}
+
+	data, err := ui.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() failed: %s", err)
+	}
+
+	var got UI
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary() failed: %s", err)
+	}
+
+	if got.Epoch != ui.Epoch || got.Counter != ui.Counter {
+		t.Errorf("Epoch/Counter mismatch: got %+v, want %+v", got, ui)
+	}
+	if !bytes.Equal(got.Cert, ui.Cert) {
+		t.Errorf("Cert mismatch: got %x, want %x", got.Cert, ui.Cert)
+	}
+	if got.CertAlgID != ui.CertAlgID {
+		t.Errorf("CertAlgID mismatch: got %v, want %v", got.CertAlgID, ui.CertAlgID)
+	}
+	if len(got.Extensions) != 1 || got.Extensions[0].Tag != 1 || !bytes.Equal(got.Extensions[0].Value, []byte("ext-value")) {
+		t.Errorf("Extensions mismatch: got %+v", got.Extensions)
+	}
+}
+
+func TestUIRoundTripEmptyCert(t *testing.T) {
+	ui := &UI{Epoch: 1, Counter: 1, CertAlgID: CertAlgHMAC}
+
+	data, err := ui.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() failed: %s", err)
+	}
+
+	var got UI
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary() failed: %s", err)
+	}
+	if len(got.Cert) != 0 {
+		t.Errorf("Cert = %x, want empty", got.Cert)
+	}
+}
+
+func TestUnmarshalUIV0(t *testing.T) {
+	legacy := &UI{Epoch: 3, Counter: 9, Cert: []byte("legacy-cert")}
+	data, err := legacy.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() failed: %s", err)
+	}
+	// Strip the framing this test package now produces so data looks
+	// like a genuinely pre-framing, persisted v0 blob
+	v0 := append(append(make([]byte, 0, 16+len(legacy.Cert)),
+		data[2:18]...), legacy.Cert...)
+
+	got, err := UnmarshalUIV0(v0)
+	if err != nil {
+		t.Fatalf("UnmarshalUIV0() failed: %s", err)
+	}
+	if got.Epoch != legacy.Epoch || got.Counter != legacy.Counter {
+		t.Errorf("Epoch/Counter mismatch: got %+v, want %+v", got, legacy)
+	}
+	if !bytes.Equal(got.Cert, legacy.Cert) {
+		t.Errorf("Cert mismatch: got %x, want %x", got.Cert, legacy.Cert)
+	}
+}
+
+func TestUnmarshalBinaryRejectsUnknownVersion(t *testing.T) {
+	data := []byte{0x7f, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	var ui UI
+	if err := ui.UnmarshalBinary(data); err == nil {
+		t.Error("UnmarshalBinary() with unknown version succeeded, want error")
+	}
+}
+
+func FuzzUIUnmarshalBinary(f *testing.F) {
+	seed := &UI{
+		Epoch:      1,
+		Counter:    2,
+		Cert:       []byte("seed-cert"),
+		CertAlgID:  CertAlgHMAC,
+		Extensions: []UIExtension{{Tag: 1, Value: []byte("seed-ext")}},
+	}
+	if data, err := seed.MarshalBinary(); err == nil {
+		f.Add(data)
+	}
+	f.Add([]byte{})
+	f.Add([]byte{uiVersion1})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var ui UI
+		// Must never panic on arbitrary input; errors are fine
+		_ = ui.UnmarshalBinary(data)
+	})
+}